@@ -0,0 +1,143 @@
+// clientip.go
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// clientIPResolver extracts the real client IP from a request, honoring
+// X-Forwarded-For/X-Real-IP/Forwarded only when the direct peer is inside
+// one of trustedProxies. With no trusted proxies configured it always falls
+// back to the raw RemoteAddr, matching the previous behavior.
+type clientIPResolver struct {
+	trustedProxies []*net.IPNet
+	headerOrder    []string // header lookup order, e.g. {"X-Real-IP", "X-Forwarded-For", "Forwarded"}
+}
+
+// newClientIPResolver parses cidrs (comma-separated CIDRs) into a resolver.
+// An empty cidrs list yields a resolver that trusts nothing; any entry that
+// is not a valid CIDR makes it return an error.
+func newClientIPResolver(cidrs string, headerOrder []string) (*clientIPResolver, error) {
+	resolver := &clientIPResolver{headerOrder: headerOrder}
+	if len(resolver.headerOrder) == 0 {
+		resolver.headerOrder = []string{"X-Real-IP", "X-Forwarded-For", "Forwarded"}
+	}
+	for _, raw := range strings.Split(cidrs, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(raw)
+		if err != nil {
+			return nil, err
+		}
+		resolver.trustedProxies = append(resolver.trustedProxies, ipNet)
+	}
+	return resolver, nil
+}
+
+// isTrusted reports whether ip falls inside any configured trusted-proxy CIDR.
+func (c *clientIPResolver) isTrusted(ip net.IP) bool {
+	for _, ipNet := range c.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the resolved client IP and, when headers were consulted,
+// the full forwarding chain (in header order, original client first) for display.
+func (c *clientIPResolver) Resolve(r *http.Request) (clientIP string, forwardedFor []string) {
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIP = r.RemoteAddr
+	}
+	peer := net.ParseIP(remoteIP)
+	if peer == nil || !c.isTrusted(peer) {
+		return remoteIP, nil
+	}
+
+	for _, header := range c.headerOrder {
+		switch textproto.CanonicalMIMEHeaderKey(header) {
+		case "X-Real-Ip":
+			if v := r.Header.Get("X-Real-Ip"); v != "" {
+				return v, []string{v}
+			}
+		case "X-Forwarded-For":
+			if chain := splitForwardedFor(r.Header.Get("X-Forwarded-For")); len(chain) > 0 {
+				if ip := firstUntrusted(chain, c); ip != "" {
+					return ip, chain
+				}
+			}
+		case "Forwarded":
+			if chain := parseForwardedHeader(r.Header.Get("Forwarded")); len(chain) > 0 {
+				if ip := firstUntrusted(chain, c); ip != "" {
+					return ip, chain
+				}
+			}
+		}
+	}
+	return remoteIP, nil
+}
+
+// splitForwardedFor splits a raw X-Forwarded-For header into its comma
+// separated IP entries, trimming whitespace.
+func splitForwardedFor(header string) []string {
+	if header == "" {
+		return nil
+	}
+	parts := strings.Split(header, ",")
+	chain := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if ip := strings.TrimSpace(p); ip != "" {
+			chain = append(chain, ip)
+		}
+	}
+	return chain
+}
+
+// parseForwardedHeader extracts the "for=" IPs from an RFC 7239 Forwarded
+// header, in the order they appear.
+func parseForwardedHeader(header string) []string {
+	if header == "" {
+		return nil
+	}
+	var chain []string
+	for _, element := range strings.Split(header, ",") {
+		for _, pair := range strings.Split(element, ";") {
+			pair = strings.TrimSpace(pair)
+			if !strings.HasPrefix(strings.ToLower(pair), "for=") {
+				continue
+			}
+			value := strings.TrimPrefix(pair[len("for="):], "")
+			value = strings.Trim(value, `"`)
+			value = strings.TrimPrefix(value, "[")
+			value = strings.TrimSuffix(value, "]")
+			if host, _, err := net.SplitHostPort(value); err == nil {
+				value = host
+			}
+			if value != "" {
+				chain = append(chain, value)
+			}
+		}
+	}
+	return chain
+}
+
+// firstUntrusted walks chain right-to-left, returning the first entry that is
+// not itself a trusted proxy. Falls back to the leftmost (original) entry if
+// every hop is trusted.
+func firstUntrusted(chain []string, c *clientIPResolver) string {
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := net.ParseIP(chain[i])
+		if ip == nil || !c.isTrusted(ip) {
+			return chain[i]
+		}
+	}
+	return chain[0]
+}
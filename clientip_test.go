@@ -0,0 +1,32 @@
+// clientip_test.go
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestResolveHeaderOrderIsCaseInsensitive verifies that a --client-ip-header
+// value typed in a non-canonical case (as a user would commonly type it)
+// still matches the corresponding header instead of silently falling back to
+// RemoteAddr.
+func TestResolveHeaderOrderIsCaseInsensitive(t *testing.T) {
+	resolver, err := newClientIPResolver("10.0.0.0/8", []string{"x-forwarded-for"})
+	if err != nil {
+		t.Fatalf("newClientIPResolver: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+
+	clientIP, forwardedFor := resolver.Resolve(req)
+	if clientIP != "203.0.113.9" {
+		t.Fatalf("clientIP = %q, want %q", clientIP, "203.0.113.9")
+	}
+	if len(forwardedFor) != 1 || forwardedFor[0] != "203.0.113.9" {
+		t.Fatalf("forwardedFor = %v, want [%q]", forwardedFor, "203.0.113.9")
+	}
+}
@@ -0,0 +1,119 @@
+// events_api.go
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// --- /api/events REST API ---
+
+// eventsListHandler handles GET /api/events, listing stored events filtered
+// by the query string (method, status range, path glob, time range, latency
+// threshold) with cursor-based pagination.
+func eventsListHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		filter, err := parseQueryFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		events, err := store.Query(filter)
+		if err != nil {
+			http.Error(w, "Failed to query events: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, events)
+	}
+}
+
+// eventsGetOrReplayHandler handles GET /api/events/{id} and POST
+// /api/events/{id}/replay.
+func eventsGetOrReplayHandler(store Store, backendAddr, broadcastURL string, enablePrint, enableBroadcast, appModeEnabled bool, ipResolver *clientIPResolver, sinks *SinkManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/events/")
+		if id == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if replayID, ok := strings.CutSuffix(id, "/replay"); ok {
+			if r.Method != http.MethodPost {
+				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			replayEventHandler(store, backendAddr, broadcastURL, enablePrint, enableBroadcast, appModeEnabled, ipResolver, sinks, replayID, w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		event, err := store.Get(id)
+		if err != nil {
+			http.Error(w, "Event not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, event)
+	}
+}
+
+// replayEventHandler reissues the request captured by the event with the
+// given id through proxyHandler against the configured backend, recording a
+// new event linked to the original via CausationID.
+func replayEventHandler(store Store, backendAddr, broadcastURL string, enablePrint, enableBroadcast, appModeEnabled bool, ipResolver *clientIPResolver, sinks *SinkManager, id string, w http.ResponseWriter, r *http.Request) {
+	original, err := store.Get(id)
+	if err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+
+	replayReq, err := buildReplayRequest(original.Data)
+	if err != nil {
+		http.Error(w, "Failed to build replay request: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctx := context.WithValue(replayReq.Context(), replayOfKey{}, original.ID)
+	replayReq = replayReq.WithContext(ctx)
+
+	var replayEvent CloudEvent
+	handler := proxyHandler(backendAddr, broadcastURL, enablePrint, enableBroadcast, appModeEnabled, store, ipResolver, protocolSet{}, sinks, func(event CloudEvent) {
+		replayEvent = event
+	})
+	handler(httptest.NewRecorder(), replayReq)
+
+	writeJSON(w, http.StatusOK, replayEvent)
+}
+
+// buildReplayRequest reconstructs an *http.Request from captured InspectrData
+// suitable for feeding back into proxyHandler.
+func buildReplayRequest(data InspectrData) (*http.Request, error) {
+	req, err := http.NewRequest(data.Method, data.URL, strings.NewReader(data.Request.Payload))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range data.Request.Headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.RemoteAddr = data.ClientIP + ":0"
+	return req, nil
+}
+
+// writeJSON marshals v as JSON and writes it with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Failed to encode JSON response:", err)
+	}
+}
@@ -0,0 +1,52 @@
+// events_api_test.go
+
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestReplayEventHandlerLinksCausationID verifies that replaying a captured
+// event reissues it against the backend and stamps the new event's
+// CausationID with the original event's ID.
+func TestReplayEventHandlerLinksCausationID(t *testing.T) {
+	backend := httptest.NewServer(nil)
+	defer backend.Close()
+
+	store := NewMemoryStore(10)
+	original := CloudEvent{
+		ID: "original-1",
+		Data: InspectrData{
+			Method: "GET",
+			URL:    backend.URL + "/hello",
+		},
+	}
+	if err := store.Append(original); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	ipResolver, err := newClientIPResolver("", nil)
+	if err != nil {
+		t.Fatalf("newClientIPResolver: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/events/original-1/replay", nil)
+
+	var replayEvent CloudEvent
+	replayEventHandler(store, backend.URL, "", false, false, false, ipResolver, nil, "original-1", rec, req)
+
+	events, err := store.Query(QueryFilter{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	for _, e := range events {
+		if e.ID != "original-1" {
+			replayEvent = e
+		}
+	}
+	if replayEvent.Data.CausationID != "original-1" {
+		t.Fatalf("CausationID = %q, want %q", replayEvent.Data.CausationID, "original-1")
+	}
+}
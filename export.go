@@ -0,0 +1,563 @@
+// export.go
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --- HAR export ---
+
+// harVersion is the HAR format version produced and accepted by this proxy.
+const harVersion = "1.2"
+
+// HARLog is the top-level HAR document.
+type HARLog struct {
+	Log HARLogBody `json:"log"`
+}
+
+// HARLogBody holds the HAR creator metadata and captured entries.
+type HARLogBody struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator identifies the tool that produced the HAR log.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a single captured request/response pair.
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            int64       `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+}
+
+// HARRequest is the request half of a HAR entry.
+type HARRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Headers     []HARNVPair  `json:"headers"`
+	QueryString []HARNVPair  `json:"queryString"`
+	PostData    *HARPostData `json:"postData,omitempty"`
+}
+
+// HARResponse is the response half of a HAR entry.
+type HARResponse struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Headers     []HARNVPair `json:"headers"`
+	Content     HARContent  `json:"content"`
+}
+
+// HARNVPair is a HAR name/value pair, used for headers and query params.
+type HARNVPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData carries a request body.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent carries a response body.
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// harExportHandler handles GET /api/export/har, rendering the events matching
+// the request's query filters (same filters as /api/events) as a HAR 1.2 log.
+func harExportHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		filter, err := parseQueryFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if filter.Limit == 0 {
+			filter.Limit = 100
+		}
+		events, err := store.Query(filter)
+		if err != nil {
+			http.Error(w, "Failed to query events: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		entries := make([]HAREntry, 0, len(events))
+		for _, event := range events {
+			entries = append(entries, toHAREntry(event.Data))
+		}
+		har := HARLog{Log: HARLogBody{
+			Version: harVersion,
+			Creator: HARCreator{Name: "inspectr-proxy", Version: harVersion},
+			Entries: entries,
+		}}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="inspectr.har"`)
+		if err := json.NewEncoder(w).Encode(har); err != nil {
+			log.Println("Failed to encode HAR export:", err)
+		}
+	}
+}
+
+// toHAREntry converts captured InspectrData into a HAR entry.
+func toHAREntry(data InspectrData) HAREntry {
+	return HAREntry{
+		StartedDateTime: data.Request.Timestamp,
+		Time:            data.Latency,
+		Request: HARRequest{
+			Method:      data.Method,
+			URL:         data.URL,
+			HTTPVersion: "HTTP/1.1",
+			Headers:     toHARPairs(data.Request.Headers),
+			QueryString: toHARPairs(data.Request.QueryParams),
+			PostData:    toHARPostData(data.Request.Headers, data.Request.Payload),
+		},
+		Response: HARResponse{
+			Status:      data.Response.StatusCode,
+			StatusText:  data.Response.StatusMessage,
+			HTTPVersion: "HTTP/1.1",
+			Headers:     toHARPairs(data.Response.Headers),
+			Content: HARContent{
+				Size:     len(data.Response.Payload),
+				MimeType: headerValue(data.Response.Headers, "Content-Type"),
+				Text:     data.Response.Payload,
+			},
+		},
+	}
+}
+
+// toHARPairs flattens a multi-value header/query map into HAR name/value pairs.
+func toHARPairs(values map[string][]string) []HARNVPair {
+	pairs := make([]HARNVPair, 0, len(values))
+	for name, vals := range values {
+		for _, v := range vals {
+			pairs = append(pairs, HARNVPair{Name: name, Value: v})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+// toHARPostData wraps a request body as HAR postData, or nil if there is none.
+func toHARPostData(headers map[string][]string, payload string) *HARPostData {
+	if payload == "" {
+		return nil
+	}
+	return &HARPostData{MimeType: headerValue(headers, "Content-Type"), Text: payload}
+}
+
+// headerValue returns the first value of the named header, case-insensitively.
+func headerValue(headers map[string][]string, name string) string {
+	for key, vals := range headers {
+		if strings.EqualFold(key, name) && len(vals) > 0 {
+			return vals[0]
+		}
+	}
+	return ""
+}
+
+// --- HAR import ---
+
+// harImportHandler handles POST /api/import/har, replaying every entry in the
+// uploaded HAR log against the configured backend and recording a new event
+// for each. It reuses the same capture pipeline as a live request.
+func harImportHandler(backendAddr, broadcastURL string, enablePrint, enableBroadcast, appModeEnabled bool, store Store, ipResolver *clientIPResolver, sinks *SinkManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var har HARLog
+		if err := json.NewDecoder(r.Body).Decode(&har); err != nil {
+			http.Error(w, "Invalid HAR document: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		imported := make([]CloudEvent, 0, len(har.Log.Entries))
+		for _, entry := range har.Log.Entries {
+			req, err := buildHARReplayRequest(entry)
+			if err != nil {
+				log.Println("Skipping unreplayable HAR entry:", err)
+				continue
+			}
+			var replayed CloudEvent
+			handler := proxyHandler(backendAddr, broadcastURL, enablePrint, enableBroadcast, appModeEnabled, store, ipResolver, protocolSet{}, sinks, func(event CloudEvent) {
+				replayed = event
+			})
+			handler(httptest.NewRecorder(), req)
+			imported = append(imported, replayed)
+		}
+
+		writeJSON(w, http.StatusOK, imported)
+	}
+}
+
+// buildHARReplayRequest reconstructs an *http.Request from a HAR entry's
+// request section, suitable for feeding into proxyHandler. Per the HAR 1.2
+// spec, entry.Request.URL is an absolute URL; only its path and query are
+// kept; any scheme/host it carries is discarded so replay always targets
+// the configured --backend and a HAR harvested elsewhere can't be used to
+// redirect requests to arbitrary hosts.
+func buildHARReplayRequest(entry HAREntry) (*http.Request, error) {
+	var body string
+	if entry.Request.PostData != nil {
+		body = entry.Request.PostData.Text
+	}
+	parsedURL, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse entry URL: %w", err)
+	}
+	requestURL := &url.URL{Path: parsedURL.Path, RawQuery: parsedURL.RawQuery}
+	req, err := http.NewRequest(entry.Request.Method, requestURL.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range entry.Request.Headers {
+		req.Header.Add(h.Name, h.Value)
+	}
+	return req, nil
+}
+
+// --- OpenAPI export ---
+
+// openapiExportHandler handles GET /api/export/openapi, inferring an OpenAPI
+// 3.1 document from the captured traffic: paths are generalized by replacing
+// ID-like segments with templated parameters, and request/response schemas
+// are unified across all sampled events for a given method/path template.
+func openapiExportHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		events, err := store.Query(QueryFilter{Limit: 10000})
+		if err != nil {
+			http.Error(w, "Failed to query events: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		spec := buildOpenAPISpec(events)
+		writeJSON(w, http.StatusOK, spec)
+	}
+}
+
+// operationSample collects the request/response bodies captured for one
+// method/path-template combination, used to infer a unified schema.
+type operationSample struct {
+	method        string
+	pathTemplate  string
+	requestBodies []interface{}
+	responses     map[int][]interface{}
+}
+
+// buildOpenAPISpec groups events by method and templated path, then emits a
+// minimal OpenAPI 3.1 document with inferred request/response schemas.
+func buildOpenAPISpec(events []CloudEvent) map[string]interface{} {
+	root := newPathSegmentNode()
+	for _, event := range events {
+		root.insertPath(strings.Split(event.Data.Path, "/"))
+	}
+	root.collapseIDs()
+
+	samples := map[string]*operationSample{}
+	var order []string
+	for _, event := range events {
+		data := event.Data
+		tmpl := strings.Join(root.templatize(strings.Split(data.Path, "/")), "/")
+		key := data.Method + " " + tmpl
+		s, ok := samples[key]
+		if !ok {
+			s = &operationSample{method: data.Method, pathTemplate: tmpl, responses: map[int][]interface{}{}}
+			samples[key] = s
+			order = append(order, key)
+		}
+		if v, ok := parseJSONBody(data.Request.Headers, data.Request.Payload); ok {
+			s.requestBodies = append(s.requestBodies, v)
+		}
+		if v, ok := parseJSONBody(data.Response.Headers, data.Response.Payload); ok {
+			s.responses[data.Response.StatusCode] = append(s.responses[data.Response.StatusCode], v)
+		}
+	}
+	sort.Strings(order)
+
+	paths := map[string]interface{}{}
+	for _, key := range order {
+		s := samples[key]
+		methodLower := strings.ToLower(s.method)
+		operation := map[string]interface{}{
+			"summary": s.method + " " + s.pathTemplate,
+		}
+		if len(s.requestBodies) > 0 {
+			operation["requestBody"] = map[string]interface{}{
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": inferSchema(s.requestBodies),
+					},
+				},
+			}
+		}
+		responses := map[string]interface{}{}
+		for status, bodies := range s.responses {
+			responses[strconv.Itoa(status)] = map[string]interface{}{
+				"description": http.StatusText(status),
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": inferSchema(bodies),
+					},
+				},
+			}
+		}
+		if len(responses) == 0 {
+			responses["default"] = map[string]interface{}{"description": "Response"}
+		}
+		operation["responses"] = responses
+
+		pathItem, ok := paths[s.pathTemplate].(map[string]interface{})
+		if !ok {
+			pathItem = map[string]interface{}{}
+			paths[s.pathTemplate] = pathItem
+		}
+		pathItem[methodLower] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   "Inspectr captured traffic",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+	}
+}
+
+// numericSegment matches a path segment made up entirely of digits.
+var numericSegment = regexp.MustCompile(`^[0-9]+$`)
+
+// uuidSegment matches a path segment formatted as a UUID.
+var uuidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// idSegmentThreshold is the number of distinct numeric- or UUID-shaped
+// values a path segment may take, across every captured request sharing
+// the same position, and still be kept literal; more than that generalizes
+// it to {id}. A segment that is numeric- or UUID-shaped in a single sample
+// (e.g. the "2" in "/api/2/...") is assumed to be a static literal, not an
+// identifier.
+const idSegmentThreshold = 1
+
+// pathSegmentNode is one level of the trie built over every captured path's
+// segments. Templating a path requires knowing how many distinct values a
+// segment takes across the whole corpus, not just within one path, so paths
+// are inserted first and the {id}-worthy segments are decided afterwards.
+type pathSegmentNode struct {
+	children map[string]*pathSegmentNode
+}
+
+func newPathSegmentNode() *pathSegmentNode {
+	return &pathSegmentNode{children: map[string]*pathSegmentNode{}}
+}
+
+// insertPath records one captured path's segments into the trie.
+func (n *pathSegmentNode) insertPath(segments []string) {
+	node := n
+	for _, seg := range segments {
+		child, ok := node.children[seg]
+		if !ok {
+			child = newPathSegmentNode()
+			node.children[seg] = child
+		}
+		node = child
+	}
+}
+
+// mergeFrom unions other's subtree into n's, used when collapseIDs folds
+// several id-shaped children into one {id} child.
+func (n *pathSegmentNode) mergeFrom(other *pathSegmentNode) {
+	for seg, child := range other.children {
+		if existing, ok := n.children[seg]; ok {
+			existing.mergeFrom(child)
+		} else {
+			n.children[seg] = child
+		}
+	}
+}
+
+// collapseIDs walks the trie and, at every node where more than
+// idSegmentThreshold distinct numeric- or UUID-shaped children are present,
+// folds them into a single "{id}" child (unioning their subtrees so deeper
+// segments still see every sample that passed through them).
+func (n *pathSegmentNode) collapseIDs() {
+	var idLike []string
+	for seg := range n.children {
+		if numericSegment.MatchString(seg) || uuidSegment.MatchString(seg) {
+			idLike = append(idLike, seg)
+		}
+	}
+	if len(idLike) > idSegmentThreshold {
+		merged := newPathSegmentNode()
+		for _, seg := range idLike {
+			merged.mergeFrom(n.children[seg])
+			delete(n.children, seg)
+		}
+		n.children["{id}"] = merged
+	}
+	for _, child := range n.children {
+		child.collapseIDs()
+	}
+}
+
+// templatize maps one path's segments through the trie, following the
+// literal edge where one was recorded and otherwise falling back to the
+// "{id}" edge collapseIDs created for that position.
+func (n *pathSegmentNode) templatize(segments []string) []string {
+	node := n
+	out := make([]string, len(segments))
+	for i, seg := range segments {
+		child, ok := node.children[seg]
+		if !ok {
+			if idChild, isID := node.children["{id}"]; isID {
+				seg, child = "{id}", idChild
+			}
+		}
+		out[i] = seg
+		if child == nil {
+			child = newPathSegmentNode()
+		}
+		node = child
+	}
+	return out
+}
+
+// parseJSONBody decodes payload as JSON if its content type (or its shape,
+// absent a content type) indicates JSON.
+func parseJSONBody(headers map[string][]string, payload string) (interface{}, bool) {
+	if strings.TrimSpace(payload) == "" {
+		return nil, false
+	}
+	contentType := headerValue(headers, "Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "json") {
+		return nil, false
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(payload), &v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// inferSchema unifies a JSON Schema (draft-07 subset) describing every value
+// in samples. Object fields are unioned across samples; a field is only
+// marked required if present in every sample that is an object.
+func inferSchema(samples []interface{}) map[string]interface{} {
+	if len(samples) == 0 {
+		return map[string]interface{}{}
+	}
+
+	types := map[string]bool{}
+	for _, v := range samples {
+		types[jsonSchemaType(v)] = true
+	}
+	if len(types) > 1 {
+		typeList := make([]string, 0, len(types))
+		for t := range types {
+			typeList = append(typeList, t)
+		}
+		sort.Strings(typeList)
+		return map[string]interface{}{"type": typeList}
+	}
+
+	schemaType := jsonSchemaType(samples[0])
+	switch schemaType {
+	case "object":
+		fieldValues := map[string][]interface{}{}
+		presentCount := map[string]int{}
+		objectCount := 0
+		for _, v := range samples {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			objectCount++
+			for k, fv := range obj {
+				fieldValues[k] = append(fieldValues[k], fv)
+				presentCount[k]++
+			}
+		}
+		properties := map[string]interface{}{}
+		var required []string
+		names := make([]string, 0, len(fieldValues))
+		for name := range fieldValues {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			properties[name] = inferSchema(fieldValues[name])
+			if presentCount[name] == objectCount {
+				required = append(required, name)
+			}
+		}
+		sort.Strings(required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	case "array":
+		var elements []interface{}
+		for _, v := range samples {
+			if arr, ok := v.([]interface{}); ok {
+				elements = append(elements, arr...)
+			}
+		}
+		if len(elements) == 0 {
+			return map[string]interface{}{"type": "array"}
+		}
+		return map[string]interface{}{"type": "array", "items": inferSchema(elements)}
+	default:
+		return map[string]interface{}{"type": schemaType}
+	}
+}
+
+// jsonSchemaType maps a decoded JSON value to its JSON Schema type name.
+func jsonSchemaType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "string"
+	}
+}
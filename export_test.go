@@ -0,0 +1,66 @@
+// export_test.go
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestBuildHARReplayRequestDropsRecordedHost verifies that an absolute URL
+// recorded in a HAR entry (as required by the HAR 1.2 spec) does not leak
+// into the replay request; only its path and query should survive, so
+// proxyHandler resolves the request against the configured --backend
+// instead of whatever host the HAR happened to record.
+func TestBuildHARReplayRequestDropsRecordedHost(t *testing.T) {
+	entry := HAREntry{
+		Request: HARRequest{
+			Method: "GET",
+			URL:    "https://attacker.example/internal/admin?x=1",
+		},
+	}
+	req, err := buildHARReplayRequest(entry)
+	if err != nil {
+		t.Fatalf("buildHARReplayRequest: %v", err)
+	}
+	if req.URL.Host != "" || req.URL.Scheme != "" {
+		t.Fatalf("URL = %q, want host/scheme stripped", req.URL.String())
+	}
+	if req.URL.Path != "/internal/admin" {
+		t.Fatalf("Path = %q, want %q", req.URL.Path, "/internal/admin")
+	}
+	if req.URL.RawQuery != "x=1" {
+		t.Fatalf("RawQuery = %q, want %q", req.URL.RawQuery, "x=1")
+	}
+}
+
+// TestPathTemplateRequiresCardinality verifies that a numeric-looking
+// segment is generalized to {id} once more than one distinct value is
+// observed at that position.
+func TestPathTemplateRequiresCardinality(t *testing.T) {
+	events := []CloudEvent{
+		{Data: InspectrData{Method: "GET", Path: "/v2/users/1"}},
+		{Data: InspectrData{Method: "GET", Path: "/v2/users/2"}},
+	}
+
+	root := newPathSegmentNode()
+	for _, e := range events {
+		root.insertPath(strings.Split(e.Data.Path, "/"))
+	}
+	root.collapseIDs()
+
+	got := strings.Join(root.templatize(strings.Split("/v2/users/1", "/")), "/")
+	const want = "/v2/users/{id}"
+	if got != want {
+		t.Fatalf("templatize = %q, want %q", got, want)
+	}
+}
+
+// TestBuildOpenAPISpecEmits31 verifies the generated document declares
+// OpenAPI 3.1, matching the request this was built against.
+func TestBuildOpenAPISpecEmits31(t *testing.T) {
+	spec := buildOpenAPISpec(nil)
+	if got := spec["openapi"]; got != "3.1.0" {
+		t.Fatalf("openapi = %v, want %q", got, "3.1.0")
+	}
+}
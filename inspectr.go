@@ -12,13 +12,15 @@ import (
 	"io/fs"
 	"io/ioutil"
 	"log"
-	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // --- Embedded Static Files ---
@@ -28,14 +30,19 @@ var embeddedAppFS embed.FS
 
 // InspectrData represents the data schema for request/response capture.
 type InspectrData struct {
-	Method   string          `json:"method"`
-	URL      string          `json:"url"`
-	Server   string          `json:"server"`
-	Path     string          `json:"path"`
-	ClientIP string          `json:"clientIp"`
-	Latency  int64           `json:"latency"` // in milliseconds
-	Request  RequestDetails  `json:"request"`
-	Response ResponseDetails `json:"response"`
+	Method       string          `json:"method"`
+	URL          string          `json:"url"`
+	Server       string          `json:"server"`
+	Path         string          `json:"path"`
+	ClientIP     string          `json:"clientIp"`
+	ForwardedFor []string        `json:"forwardedFor,omitempty"` // resolved proxy chain, original client first
+	Latency      int64           `json:"latency"`                // in milliseconds
+	Request      RequestDetails  `json:"request"`
+	Response     ResponseDetails `json:"response"`
+	CausationID  string          `json:"causationId,omitempty"` // ID of the original event this one replays, if any
+	Protocol     string          `json:"protocol,omitempty"`    // "http" (default), "websocket", or "grpc"
+	Direction    string          `json:"direction,omitempty"`   // message direction for streaming protocols: "inbound" or "outbound"
+	MatchedRule  string          `json:"matchedRule,omitempty"` // name of the rule that handled the request, if any
 }
 
 // RequestDetails holds details of the incoming HTTP request.
@@ -142,45 +149,63 @@ var (
 )
 
 // sseHandler handles GET requests to /sse to establish an SSE connection.
-func sseHandler(w http.ResponseWriter, r *http.Request) {
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
-		return
-	}
-	// Set SSE headers.
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	// Write an initial comment to keep connection alive.
-	fmt.Fprintf(w, ": connected\n\n")
-	flusher.Flush()
-
-	// Generate a unique client ID.
-	clientID := uuid.New().String()
-	msgChan := make(chan string)
-	sseClientsMu.Lock()
-	sseClients[clientID] = msgChan
-	sseClientsMu.Unlock()
-	//log.Printf("🟢 SSE client connected: %s, total clients: %d", clientID, len(sseClients))
-
-	// Listen for messages and write them to the ResponseWriter.
-	notify := r.Context().Done()
-	for {
-		select {
-		case msg := <-msgChan:
-			fmt.Fprintf(w, "data: %s\n\n", msg)
-			flusher.Flush()
-		case <-notify:
-			sseClientsMu.Lock()
-			delete(sseClients, clientID)
-			sseClientsMu.Unlock()
-			//log.Printf("🔴 SSE client disconnected: %s", clientID)
+// When replayBuffer is non-nil, the last buffered events are streamed to the
+// newly-connected client before live events start flowing.
+func sseHandler(replayBuffer *MemoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
 			return
 		}
+		// Set SSE headers.
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		// Write an initial comment to keep connection alive.
+		fmt.Fprintf(w, ": connected\n\n")
+		flusher.Flush()
+
+		// Generate a unique client ID.
+		clientID := uuid.New().String()
+		msgChan := make(chan string)
+		sseClientsMu.Lock()
+		sseClients[clientID] = msgChan
+		sseClientsMu.Unlock()
+		//log.Printf("🟢 SSE client connected: %s, total clients: %d", clientID, len(sseClients))
+
+		// Replay the most recent buffered events so new clients are caught up.
+		if replayBuffer != nil {
+			for _, event := range replayBuffer.Last(sseReplayCount) {
+				if payload, err := json.Marshal(event); err == nil {
+					fmt.Fprintf(w, "data: %s\n\n", payload)
+				}
+			}
+			flusher.Flush()
+		}
+
+		// Listen for messages and write them to the ResponseWriter.
+		notify := r.Context().Done()
+		for {
+			select {
+			case msg := <-msgChan:
+				fmt.Fprintf(w, "data: %s\n\n", msg)
+				flusher.Flush()
+			case <-notify:
+				sseClientsMu.Lock()
+				delete(sseClients, clientID)
+				sseClientsMu.Unlock()
+				//log.Printf("🔴 SSE client disconnected: %s", clientID)
+				return
+			}
+		}
 	}
 }
 
+// sseReplayCount is the number of recently buffered events streamed to a
+// newly-connected SSE client before live events take over.
+const sseReplayCount = 20
+
 // ssePostHandler handles POST requests to /sse to broadcast a message to all SSE clients.
 func ssePostHandler(w http.ResponseWriter, r *http.Request) {
 	var message interface{}
@@ -224,10 +249,30 @@ func broadcastSSE(data InspectrData) {
 
 // --- Proxy Handler ---
 
+// replayOfKey is the context key used to tag a request as a replay of a
+// previously captured event, so proxyHandler can stamp CausationID.
+type replayOfKey struct{}
+
 // proxyHandler processes incoming requests. If a backend is configured,
 // it forwards the request and captures the response; otherwise it returns 200 OK.
-func proxyHandler(backendAddr, broadcastURL string, enablePrint, enableBroadcast, appModeEnabled bool) http.HandlerFunc {
+// When store is non-nil, every captured event is also appended to it. When
+// onCapture is non-nil, it is invoked synchronously with the captured event
+// before the handler returns, e.g. so a caller can inspect the replayed event.
+func proxyHandler(backendAddr, broadcastURL string, enablePrint, enableBroadcast, appModeEnabled bool, store Store, ipResolver *clientIPResolver, protocols protocolSet, sinks *SinkManager, onCapture func(CloudEvent)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		record := func(data InspectrData) {
+			recordEvent(data, enablePrint, enableBroadcast, broadcastURL, appModeEnabled, store, sinks, onCapture)
+		}
+
+		if protocols["ws"] && isWebSocketUpgrade(r) {
+			websocketProxyHandler(backendAddr, ipResolver, record)(w, r)
+			return
+		}
+		if protocols["grpc"] && isGRPCRequest(r) {
+			grpcProxyHandler(backendAddr, ipResolver, record)(w, r)
+			return
+		}
+
 		startTime := time.Now()
 
 		// Read and capture the request body.
@@ -303,11 +348,8 @@ func proxyHandler(backendAddr, broadcastURL string, enablePrint, enableBroadcast
 
 		latency := time.Since(startTime).Milliseconds()
 
-		// Extract client IP.
-		clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			clientIP = r.RemoteAddr
-		}
+		// Extract client IP, honoring trusted-proxy headers if configured.
+		clientIP, forwardedFor := ipResolver.Resolve(r)
 
 		// Build the InspectrData structure.
 		data := InspectrData{
@@ -319,9 +361,10 @@ func proxyHandler(backendAddr, broadcastURL string, enablePrint, enableBroadcast
 				}
 				return r.Host
 			}(),
-			Path:     r.URL.Path,
-			ClientIP: clientIP,
-			Latency:  latency,
+			Path:         r.URL.Path,
+			ClientIP:     clientIP,
+			ForwardedFor: forwardedFor,
+			Latency:      latency,
 			Request: RequestDetails{
 				Payload:     string(reqBodyBytes),
 				Headers:     r.Header,
@@ -336,22 +379,54 @@ func proxyHandler(backendAddr, broadcastURL string, enablePrint, enableBroadcast
 				Timestamp:     respTimestamp,
 			},
 		}
-
-		// Print log to terminal if enabled.
-		if enablePrint {
-			printLog(data)
+		if causationID, ok := r.Context().Value(replayOfKey{}).(string); ok {
+			data.CausationID = causationID
 		}
 
-		// Broadcast via HTTP POST if enabled.
-		if enableBroadcast && broadcastURL != "" {
-			go broadcast(broadcastURL, data)
-		}
+		record(data)
+	}
+}
 
-		// Broadcast via internal SSE if app mode is enabled.
-		if appModeEnabled {
-			go broadcastSSE(data)
+// recordEvent runs the side effects common to every captured event
+// (terminal log, HTTP broadcast, SSE broadcast, store append, and the
+// optional onCapture hook) regardless of which protocol produced it.
+func recordEvent(data InspectrData, enablePrint, enableBroadcast bool, broadcastURL string, appModeEnabled bool, store Store, sinks *SinkManager, onCapture func(CloudEvent)) {
+	// Print log to terminal if enabled.
+	if enablePrint {
+		printLog(data)
+	}
+
+	// Broadcast via HTTP POST if enabled.
+	if enableBroadcast && broadcastURL != "" {
+		go broadcast(broadcastURL, data)
+	}
+
+	// Broadcast via internal SSE if app mode is enabled.
+	if appModeEnabled {
+		go broadcastSSE(data)
+	}
+
+	cloudEvent := wrapInCloudEvent(data)
+
+	// Persist to the event store if one is configured.
+	if store != nil {
+		if err := store.Append(cloudEvent); err != nil {
+			log.Println("Failed to append event to store:", err)
 		}
 	}
+
+	// Fan out to any configured CloudEvents sinks. Dispatch is called
+	// synchronously, unlike broadcast/broadcastSSE above: it only blocks
+	// past the sink manager's own buffered input channel, and calling it
+	// from its own goroutine per request would let concurrent requests
+	// race to enqueue out of order, defeating per-sink delivery order.
+	if sinks != nil {
+		sinks.Dispatch(cloudEvent)
+	}
+
+	if onCapture != nil {
+		onCapture(cloudEvent)
+	}
 }
 
 // --- Main Function ---
@@ -365,11 +440,64 @@ func main() {
 	// App mode flags.
 	appMode := flag.Bool("app", false, "Start Inspectr App (serve embedded static assets and SSE endpoints)")
 	appPort := flag.String("appPort", "4004", "Port to serve the Inspectr App (default 4004)")
+	// Store flags.
+	storeKind := flag.String("store", "memory", "Event store backend: memory or bolt")
+	storePath := flag.String("store-path", "inspectr.db", "File path for the bolt event store")
+	storeSize := flag.Int("store-size", 1000, "Max number of events retained by the memory store")
+	// Client IP resolution flags.
+	trustedProxies := flag.String("trusted-proxies", "", "Comma-separated CIDRs of proxies trusted to set client-IP headers")
+	clientIPHeader := flag.String("client-ip-header", "", "Comma-separated header lookup order (default: X-Real-IP,X-Forwarded-For,Forwarded)")
+	protocolsFlag := flag.String("protocols", "http", "Comma-separated protocols to proxy: http,ws,grpc")
+	rulesPath := flag.String("rules", "", "Path to a YAML or JSON rule-based routing/mocking config")
+	// Sink flags.
+	sinksFlag := flag.String("sinks", "", "Comma-separated CloudEvents sink URIs, e.g. kafka://broker:9092/topic,nats://host/subject,http+batch://collector/events?flush=1s&max=100")
+	sinkQueueSize := flag.Int("sink-queue-size", 100, "Per-sink bounded queue size")
+	sinkDropPolicy := flag.String("sink-drop-policy", "block", "Backpressure policy when a sink's queue is full: block, drop-oldest, or drop-newest")
 	flag.Parse()
 
+	protocols := parseProtocols(*protocolsFlag)
+
 	enableBroadcast := *broadcastURL != ""
 	enablePrint := *printLogs
 
+	var headerOrder []string
+	if *clientIPHeader != "" {
+		headerOrder = strings.Split(*clientIPHeader, ",")
+	}
+	ipResolver, err := newClientIPResolver(*trustedProxies, headerOrder)
+	if err != nil {
+		log.Fatal("Invalid --trusted-proxies: ", err)
+	}
+
+	var store Store
+	var replayBuffer *MemoryStore
+	switch *storeKind {
+	case "bolt":
+		boltStore, err := NewBoltStore(*storePath)
+		if err != nil {
+			log.Fatal("Failed to open bolt store: ", err)
+		}
+		// The SSE replay-on-connect buffer is always an in-memory ring so we
+		// never pay disk I/O just to catch new UI clients up.
+		replayBuffer = NewMemoryStore(*storeSize)
+		store = multiStore{boltStore, replayBuffer}
+	case "memory":
+		memStore := NewMemoryStore(*storeSize)
+		store = memStore
+		replayBuffer = memStore
+	default:
+		log.Fatalf("Unknown --store %q, expected memory or bolt", *storeKind)
+	}
+
+	var sinks *SinkManager
+	if *sinksFlag != "" {
+		sinkList, err := parseSinks(*sinksFlag)
+		if err != nil {
+			log.Fatal("Failed to configure sinks: ", err)
+		}
+		sinks = NewSinkManager(sinkList, *sinkQueueSize, dropPolicy(*sinkDropPolicy))
+	}
+
 	// If app mode is enabled, start a separate server for the Inspectr App.
 	if *appMode {
 		// Get a sub-FS for the app folder so that the files appear at the FS root.
@@ -382,13 +510,20 @@ func main() {
 		// SSE endpoint.
 		appMux.HandleFunc("/api/sse", func(w http.ResponseWriter, r *http.Request) {
 			if r.Method == "GET" {
-				sseHandler(w, r)
+				sseHandler(replayBuffer)(w, r)
 			} else if r.Method == "POST" {
 				ssePostHandler(w, r)
 			} else {
 				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 			}
 		})
+		// Captured event query/replay API.
+		appMux.HandleFunc("/api/events", eventsListHandler(store))
+		appMux.HandleFunc("/api/events/", eventsGetOrReplayHandler(store, *backendAddr, *broadcastURL, enablePrint, enableBroadcast, *appMode, ipResolver, sinks))
+		// HAR and OpenAPI export/import of captured traffic.
+		appMux.HandleFunc("/api/export/har", harExportHandler(store))
+		appMux.HandleFunc("/api/export/openapi", openapiExportHandler(store))
+		appMux.HandleFunc("/api/import/har", harImportHandler(*backendAddr, *broadcastURL, enablePrint, enableBroadcast, *appMode, store, ipResolver, sinks))
 		// Serve embedded static assets from the sub filesystem at root.
 		appMux.Handle("/", http.FileServer(http.FS(appStatic)))
 		go func() {
@@ -399,10 +534,31 @@ func main() {
 		}()
 	}
 
-	// Register the proxy handler on the main mux.
-	http.HandleFunc("/", proxyHandler(*backendAddr, *broadcastURL, enablePrint, enableBroadcast, *appMode))
+	// Register the main handler: a rule engine when --rules is configured,
+	// otherwise the single-backend proxy handler.
+	if *rulesPath != "" {
+		rules, err := loadRules(*rulesPath)
+		if err != nil {
+			log.Fatal("Failed to load rules: ", err)
+		}
+		engine := NewRuleEngine(rules, *backendAddr, ipResolver, func(data InspectrData) {
+			recordEvent(data, enablePrint, enableBroadcast, *broadcastURL, *appMode, store, sinks, nil)
+		})
+		watchRuleReload(*rulesPath, engine)
+		http.Handle("/", engine)
+	} else {
+		http.HandleFunc("/", proxyHandler(*backendAddr, *broadcastURL, enablePrint, enableBroadcast, *appMode, store, ipResolver, protocols, sinks, nil))
+	}
 	log.Printf("Inspectr Proxy server listening on %s", *listenAddr)
-	if err := http.ListenAndServe(*listenAddr, nil); err != nil {
+	// --protocols=grpc relies on isGRPCRequest seeing ProtoMajor == 2, which
+	// the stdlib server never negotiates over plain TCP without TLS. Wrap the
+	// handler with h2c so gRPC's cleartext HTTP/2 preface is accepted; this
+	// is a no-op for the default http/ws-only configuration.
+	var handler http.Handler
+	if protocols["grpc"] {
+		handler = h2c.NewHandler(http.DefaultServeMux, &http2.Server{})
+	}
+	if err := http.ListenAndServe(*listenAddr, handler); err != nil {
 		log.Fatal("Inspectr Proxy server error:", err)
 	}
 }
@@ -0,0 +1,651 @@
+// rules.go
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --- Rule config schema ---
+
+// RuleConfig is the top-level document loaded from --rules.
+type RuleConfig struct {
+	Upstreams map[string]string `yaml:"upstreams,omitempty" json:"upstreams,omitempty"` // name -> base URL
+	Rules     []RuleDef         `yaml:"rules" json:"rules"`
+}
+
+// RuleDef describes one routing/mocking rule: a matcher plus one of Fault,
+// Mock, Chain or Forward (the first one set, in that order, is used),
+// optionally preceded by Delay. A Fault rule that also sets Forward forwards
+// the request whenever the fault does not trigger. A rule with only Delay
+// set answers with an error.
+type RuleDef struct {
+	Name    string         `yaml:"name" json:"name"`
+	Match   MatchDef       `yaml:"match" json:"match"`
+	Forward *ForwardAction `yaml:"forward,omitempty" json:"forward,omitempty"`
+	Mock    *MockAction    `yaml:"mock,omitempty" json:"mock,omitempty"`
+	Delay   *DelayAction   `yaml:"delay,omitempty" json:"delay,omitempty"`
+	Fault   *FaultAction   `yaml:"fault,omitempty" json:"fault,omitempty"`
+	Chain   *ChainAction   `yaml:"chain,omitempty" json:"chain,omitempty"`
+}
+
+// MatchDef lists the conditions a request must satisfy for a rule to apply.
+// Zero-value fields are treated as "don't care".
+type MatchDef struct {
+	Method    string            `yaml:"method,omitempty" json:"method,omitempty"`
+	Host      string            `yaml:"host,omitempty" json:"host,omitempty"`
+	Path      string            `yaml:"path,omitempty" json:"path,omitempty"` // glob, e.g. "/api/*"
+	PathRegex string            `yaml:"pathRegex,omitempty" json:"pathRegex,omitempty"`
+	Headers   map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"` // "" value means presence-only
+	Query     map[string]string `yaml:"query,omitempty" json:"query,omitempty"`
+}
+
+// ForwardAction forwards the request to a named upstream, optionally
+// rewriting the request path first.
+type ForwardAction struct {
+	Upstream    string `yaml:"upstream" json:"upstream"`
+	RewritePath string `yaml:"rewritePath,omitempty" json:"rewritePath,omitempty"`
+}
+
+// MockAction answers the request directly, without contacting any upstream.
+// Body is rendered as a Go text/template over the incoming request if it
+// contains template actions; BodyFile, when set, is read fresh on every hit.
+type MockAction struct {
+	Status   int               `yaml:"status" json:"status"`
+	Headers  map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Body     string            `yaml:"body,omitempty" json:"body,omitempty"`
+	BodyFile string            `yaml:"bodyFile,omitempty" json:"bodyFile,omitempty"`
+}
+
+// DelayAction stalls the response by a fixed duration plus optional jitter.
+type DelayAction struct {
+	Fixed     time.Duration `yaml:"fixed,omitempty" json:"fixed,omitempty"`
+	JitterMin time.Duration `yaml:"jitterMin,omitempty" json:"jitterMin,omitempty"`
+	JitterMax time.Duration `yaml:"jitterMax,omitempty" json:"jitterMax,omitempty"`
+}
+
+// FaultAction returns Status with the given Probability (0-1) instead of
+// forwarding the request, to simulate backend failures.
+type FaultAction struct {
+	Status      int     `yaml:"status" json:"status"`
+	Probability float64 `yaml:"probability" json:"probability"`
+}
+
+// ChainAction fans the request out to multiple upstreams concurrently and
+// merges their responses into a single JSON array response.
+type ChainAction struct {
+	Upstreams []string `yaml:"upstreams" json:"upstreams"`
+}
+
+// loadRuleConfig reads and parses a rules file, choosing YAML or JSON based
+// on its extension.
+func loadRuleConfig(path string) (*RuleConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rules file: %w", err)
+	}
+	var cfg RuleConfig
+	if strings.HasSuffix(path, ".json") {
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, fmt.Errorf("parse rules JSON: %w", err)
+		}
+	} else {
+		if err := yaml.Unmarshal(raw, &cfg); err != nil {
+			return nil, fmt.Errorf("parse rules YAML: %w", err)
+		}
+	}
+	return &cfg, nil
+}
+
+// --- Rule interface and compiled rules ---
+
+// Rule matches incoming requests and, when matched, handles them end to end
+// (forwarding, mocking, delaying, etc.), returning the InspectrData captured
+// for the exchange.
+type Rule interface {
+	Name() string
+	Match(*http.Request) bool
+	Handle(w http.ResponseWriter, r *http.Request) (InspectrData, error)
+}
+
+// compiledRule is the Rule implementation built from a RuleDef.
+type compiledRule struct {
+	def       RuleDef
+	pathRegex *regexp.Regexp
+	upstreams map[string]string // name -> base URL, shared across rules
+}
+
+// compileRules validates and compiles every RuleDef in cfg against the named
+// upstreams map.
+func compileRules(cfg *RuleConfig, upstreams map[string]string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(cfg.Rules))
+	for _, def := range cfg.Rules {
+		cr := &compiledRule{def: def, upstreams: upstreams}
+		if def.Match.PathRegex != "" {
+			re, err := regexp.Compile(def.Match.PathRegex)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: invalid pathRegex: %w", def.Name, err)
+			}
+			cr.pathRegex = re
+		}
+		rules = append(rules, cr)
+	}
+	return rules, nil
+}
+
+// Name implements Rule.
+func (c *compiledRule) Name() string { return c.def.Name }
+
+// Match implements Rule.
+func (c *compiledRule) Match(r *http.Request) bool {
+	m := c.def.Match
+	if m.Method != "" && !strings.EqualFold(m.Method, r.Method) {
+		return false
+	}
+	if m.Host != "" && !strings.EqualFold(m.Host, r.Host) {
+		return false
+	}
+	if m.Path != "" {
+		if ok, err := path.Match(m.Path, r.URL.Path); err != nil || !ok {
+			return false
+		}
+	}
+	if c.pathRegex != nil && !c.pathRegex.MatchString(r.URL.Path) {
+		return false
+	}
+	for header, want := range m.Headers {
+		got := r.Header.Get(header)
+		if got == "" {
+			return false
+		}
+		if want != "" && got != want {
+			return false
+		}
+	}
+	for key, want := range m.Query {
+		got := r.URL.Query().Get(key)
+		if got == "" {
+			return false
+		}
+		if want != "" && got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// Handle implements Rule, dispatching to the configured action.
+func (c *compiledRule) Handle(w http.ResponseWriter, r *http.Request) (InspectrData, error) {
+	if c.def.Delay != nil {
+		applyDelay(*c.def.Delay)
+	}
+	switch {
+	case c.def.Fault != nil:
+		return c.handleFault(w, r)
+	case c.def.Mock != nil:
+		return c.handleMock(w, r)
+	case c.def.Chain != nil:
+		return c.handleChain(w, r)
+	case c.def.Forward != nil:
+		return c.handleForward(w, r)
+	default:
+		return writeRuleError(w, c.def.Name, r, http.StatusInternalServerError, fmt.Errorf("rule %q has no action", c.def.Name))
+	}
+}
+
+// writeRuleError writes status and err's message to w and returns the
+// InspectrData captured for that error response, so a rule's failure is
+// visible in the capture log the same as a successful response.
+func writeRuleError(w http.ResponseWriter, ruleName string, r *http.Request, status int, err error) (InspectrData, error) {
+	http.Error(w, err.Error(), status)
+	return InspectrData{
+		Method:      r.Method,
+		URL:         r.URL.String(),
+		Path:        r.URL.Path,
+		MatchedRule: ruleName,
+		Response: ResponseDetails{
+			Payload:       err.Error(),
+			StatusCode:    status,
+			StatusMessage: http.StatusText(status),
+			Timestamp:     time.Now().Format(time.RFC3339Nano),
+		},
+	}, err
+}
+
+// applyDelay sleeps for Fixed plus a random duration in [JitterMin, JitterMax).
+func applyDelay(d DelayAction) {
+	wait := d.Fixed
+	if d.JitterMax > d.JitterMin {
+		wait += d.JitterMin + time.Duration(rand.Int63n(int64(d.JitterMax-d.JitterMin)))
+	} else if d.JitterMin > 0 {
+		wait += d.JitterMin
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// handleFault returns the configured fault status with the configured
+// probability; otherwise it forwards the request (if Forward is configured)
+// or passes it through with a plain 200 OK, so the rule remains useful as a
+// "sometimes fail" wrapper.
+func (c *compiledRule) handleFault(w http.ResponseWriter, r *http.Request) (InspectrData, error) {
+	if rand.Float64() >= c.def.Fault.Probability {
+		if c.def.Forward != nil {
+			return c.handleForward(w, r)
+		}
+		return c.faultPassThrough(w, r)
+	}
+	status := c.def.Fault.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	body := fmt.Sprintf("injected fault from rule %q", c.def.Name)
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+	return InspectrData{
+		Method:      r.Method,
+		URL:         r.URL.String(),
+		Path:        r.URL.Path,
+		MatchedRule: c.def.Name,
+		Response: ResponseDetails{
+			Payload:       body,
+			StatusCode:    status,
+			StatusMessage: http.StatusText(status),
+			Timestamp:     time.Now().Format(time.RFC3339Nano),
+		},
+	}, nil
+}
+
+// faultPassThrough answers with a plain 200 OK for the case where the fault
+// didn't trigger and the rule has no Forward configured to fall back to.
+func (c *compiledRule) faultPassThrough(w http.ResponseWriter, r *http.Request) (InspectrData, error) {
+	w.WriteHeader(http.StatusOK)
+	return InspectrData{
+		Method:      r.Method,
+		URL:         r.URL.String(),
+		Path:        r.URL.Path,
+		MatchedRule: c.def.Name,
+		Response: ResponseDetails{
+			StatusCode:    http.StatusOK,
+			StatusMessage: http.StatusText(http.StatusOK),
+			Timestamp:     time.Now().Format(time.RFC3339Nano),
+		},
+	}, nil
+}
+
+// handleMock answers the request from the rule's Mock config, templating
+// Body (or the contents of BodyFile) over the request.
+func (c *compiledRule) handleMock(w http.ResponseWriter, r *http.Request) (InspectrData, error) {
+	mock := c.def.Mock
+	bodyTemplate := mock.Body
+	if mock.BodyFile != "" {
+		raw, err := os.ReadFile(mock.BodyFile)
+		if err != nil {
+			return writeRuleError(w, c.def.Name, r, http.StatusInternalServerError, fmt.Errorf("read mock bodyFile: %w", err))
+		}
+		bodyTemplate = string(raw)
+	}
+	rendered, err := renderMockBody(bodyTemplate, r)
+	if err != nil {
+		return writeRuleError(w, c.def.Name, r, http.StatusInternalServerError, fmt.Errorf("render mock body: %w", err))
+	}
+
+	for key, value := range mock.Headers {
+		w.Header().Set(key, value)
+	}
+	status := mock.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(rendered))
+
+	return InspectrData{
+		Method:      r.Method,
+		URL:         r.URL.String(),
+		Path:        r.URL.Path,
+		MatchedRule: c.def.Name,
+		Response: ResponseDetails{
+			Payload:       rendered,
+			StatusCode:    status,
+			StatusMessage: http.StatusText(status),
+			Timestamp:     time.Now().Format(time.RFC3339Nano),
+		},
+	}, nil
+}
+
+// mockTemplateData is the set of request fields exposed to mock body templates.
+type mockTemplateData struct {
+	Method  string
+	Path    string
+	Host    string
+	Query   url.Values
+	Headers http.Header
+}
+
+// renderMockBody executes bodyTemplate as a Go text/template over r's fields.
+// Templates without any {{ }} actions are returned unchanged.
+func renderMockBody(bodyTemplate string, r *http.Request) (string, error) {
+	if !strings.Contains(bodyTemplate, "{{") {
+		return bodyTemplate, nil
+	}
+	tmpl, err := template.New("mock").Parse(bodyTemplate)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	data := mockTemplateData{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Host:    r.Host,
+		Query:   r.URL.Query(),
+		Headers: r.Header,
+	}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// handleForward proxies the request to the named upstream, rewriting the
+// path first if RewritePath is set.
+func (c *compiledRule) handleForward(w http.ResponseWriter, r *http.Request) (InspectrData, error) {
+	upstream, ok := c.upstreams[c.def.Forward.Upstream]
+	if !ok {
+		return writeRuleError(w, c.def.Name, r, http.StatusBadGateway, fmt.Errorf("unknown upstream %q", c.def.Forward.Upstream))
+	}
+	data, err := forwardRequest(upstream, c.def.Forward.RewritePath, w, r)
+	data.MatchedRule = c.def.Name
+	return data, err
+}
+
+// handleChain fans the request out to every configured upstream concurrently
+// and merges the responses into a JSON array returned to the client.
+func (c *compiledRule) handleChain(w http.ResponseWriter, r *http.Request) (InspectrData, error) {
+	reqBodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		return writeRuleError(w, c.def.Name, r, http.StatusInternalServerError, fmt.Errorf("read request body: %w", err))
+	}
+
+	type chainResult struct {
+		Upstream   string `json:"upstream"`
+		StatusCode int    `json:"statusCode"`
+		Body       string `json:"body"`
+		Error      string `json:"error,omitempty"`
+	}
+	results := make([]chainResult, len(c.def.Chain.Upstreams))
+	done := make(chan int, len(c.def.Chain.Upstreams))
+	for i, name := range c.def.Chain.Upstreams {
+		go func(i int, name string) {
+			defer func() { done <- i }()
+			upstream, ok := c.upstreams[name]
+			if !ok {
+				results[i] = chainResult{Upstream: name, Error: "unknown upstream"}
+				return
+			}
+			backendURL, err := url.Parse(upstream)
+			if err != nil {
+				results[i] = chainResult{Upstream: name, Error: err.Error()}
+				return
+			}
+			req, err := http.NewRequest(r.Method, backendURL.ResolveReference(r.URL).String(), bytes.NewReader(reqBodyBytes))
+			if err != nil {
+				results[i] = chainResult{Upstream: name, Error: err.Error()}
+				return
+			}
+			req.Header = r.Header.Clone()
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				results[i] = chainResult{Upstream: name, Error: err.Error()}
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			results[i] = chainResult{Upstream: name, StatusCode: resp.StatusCode, Body: string(body)}
+		}(i, name)
+	}
+	for range c.def.Chain.Upstreams {
+		<-done
+	}
+
+	payload, err := json.Marshal(results)
+	if err != nil {
+		return writeRuleError(w, c.def.Name, r, http.StatusInternalServerError, fmt.Errorf("marshal chain results: %w", err))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+
+	return InspectrData{
+		Method:      r.Method,
+		URL:         r.URL.String(),
+		Path:        r.URL.Path,
+		MatchedRule: c.def.Name,
+		Request: RequestDetails{
+			Payload:   string(reqBodyBytes),
+			Headers:   r.Header,
+			Timestamp: time.Now().Format(time.RFC3339Nano),
+		},
+		Response: ResponseDetails{
+			Payload:       string(payload),
+			StatusCode:    http.StatusOK,
+			StatusMessage: http.StatusText(http.StatusOK),
+			Timestamp:     time.Now().Format(time.RFC3339Nano),
+		},
+	}, nil
+}
+
+// forwardRequest proxies r to upstream (optionally rewriting the path),
+// streaming the response to w and returning the captured InspectrData.
+func forwardRequest(upstream, rewritePath string, w http.ResponseWriter, r *http.Request) (InspectrData, error) {
+	reqBodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		return writeRuleError(w, "", r, http.StatusInternalServerError, fmt.Errorf("read request body: %w", err))
+	}
+
+	parsedUpstream, err := url.Parse(upstream)
+	if err != nil {
+		return writeRuleError(w, "", r, http.StatusInternalServerError, fmt.Errorf("invalid upstream %q: %w", upstream, err))
+	}
+	targetURL := *r.URL
+	if rewritePath != "" {
+		targetURL.Path = rewritePath
+	}
+	backendURL := parsedUpstream.ResolveReference(&targetURL)
+
+	newReq, err := http.NewRequest(r.Method, backendURL.String(), bytes.NewReader(reqBodyBytes))
+	if err != nil {
+		return writeRuleError(w, "", r, http.StatusInternalServerError, fmt.Errorf("create backend request: %w", err))
+	}
+	newReq.Header = r.Header.Clone()
+
+	startTime := time.Now()
+	resp, err := http.DefaultClient.Do(newReq)
+	if err != nil {
+		return writeRuleError(w, "", r, http.StatusBadGateway, fmt.Errorf("forward request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	var buf bytes.Buffer
+	io.Copy(io.MultiWriter(w, &buf), resp.Body)
+
+	return InspectrData{
+		Method:  r.Method,
+		URL:     r.URL.String(),
+		Server:  upstream,
+		Path:    r.URL.Path,
+		Latency: time.Since(startTime).Milliseconds(),
+		Request: RequestDetails{
+			Payload:     string(reqBodyBytes),
+			Headers:     r.Header,
+			QueryParams: r.URL.Query(),
+			Timestamp:   startTime.Format(time.RFC3339Nano),
+		},
+		Response: ResponseDetails{
+			Payload:       buf.String(),
+			Headers:       resp.Header,
+			StatusCode:    resp.StatusCode,
+			StatusMessage: http.StatusText(resp.StatusCode),
+			Timestamp:     time.Now().Format(time.RFC3339Nano),
+		},
+	}, nil
+}
+
+// watchRuleReload reloads engine's rule set from path whenever the process
+// receives SIGHUP, without dropping any in-flight connections: ServeHTTP
+// always reads the currently-stored rule set, and Reload only ever swaps
+// the atomic pointer.
+func watchRuleReload(path string, engine *RuleEngine) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	go func() {
+		for range sigCh {
+			rules, err := loadRules(path)
+			if err != nil {
+				log.Println("Failed to reload rules, keeping previous set:", err)
+				continue
+			}
+			engine.Reload(rules)
+			log.Printf("Reloaded %d rule(s) from %s", len(rules), path)
+		}
+	}()
+}
+
+// loadRules reads and compiles the rule set at path.
+func loadRules(path string) ([]Rule, error) {
+	cfg, err := loadRuleConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return compileRules(cfg, cfg.Upstreams)
+}
+
+// --- Rule engine: hot-reloadable rule set + HTTP handler ---
+
+// RuleEngine dispatches requests to the first matching Rule from a
+// hot-swappable rule set, falling back to a single default backend when no
+// rule matches (mirroring proxyHandler's no-rules behavior).
+type RuleEngine struct {
+	rules          atomic.Value // []Rule
+	defaultBackend string
+	ipResolver     *clientIPResolver
+	recordEvent    func(InspectrData)
+}
+
+// NewRuleEngine creates a RuleEngine with the given initial rules. recordEvent
+// is invoked with the InspectrData captured for every request, matched or not.
+// ipResolver resolves each request's client IP, honoring trusted-proxy headers,
+// the same as proxyHandler.
+func NewRuleEngine(rules []Rule, defaultBackend string, ipResolver *clientIPResolver, recordEvent func(InspectrData)) *RuleEngine {
+	e := &RuleEngine{defaultBackend: defaultBackend, ipResolver: ipResolver, recordEvent: recordEvent}
+	e.rules.Store(rules)
+	return e
+}
+
+// Reload atomically swaps in a new rule set; in-flight requests keep using
+// the rule set that was active when they started.
+func (e *RuleEngine) Reload(rules []Rule) {
+	e.rules.Store(rules)
+}
+
+// trackingResponseWriter wraps an http.ResponseWriter to record whether a
+// response has already been started, so ServeHTTP can tell a Rule that wrote
+// its own error response apart from one that returned an error without
+// writing anything.
+type trackingResponseWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (t *trackingResponseWriter) WriteHeader(status int) {
+	t.started = true
+	t.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (t *trackingResponseWriter) Write(b []byte) (int, error) {
+	t.started = true
+	return t.ResponseWriter.Write(b)
+}
+
+// ServeHTTP implements http.Handler.
+func (e *RuleEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	clientIP, forwardedFor := e.ipResolver.Resolve(r)
+
+	rules, _ := e.rules.Load().([]Rule)
+	for _, rule := range rules {
+		if !rule.Match(r) {
+			continue
+		}
+		tw := &trackingResponseWriter{ResponseWriter: w}
+		data, err := rule.Handle(tw, r)
+		if err != nil && !tw.started {
+			// Handle returned an error without writing a response itself;
+			// fall back to a generic error so the client isn't left with an
+			// implicit 200 OK.
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		data.ClientIP = clientIP
+		data.ForwardedFor = forwardedFor
+		if e.recordEvent != nil {
+			e.recordEvent(data)
+		}
+		return
+	}
+
+	if e.defaultBackend == "" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+		if e.recordEvent != nil {
+			e.recordEvent(InspectrData{
+				Method:       r.Method,
+				URL:          r.URL.String(),
+				Path:         r.URL.Path,
+				ClientIP:     clientIP,
+				ForwardedFor: forwardedFor,
+				Response: ResponseDetails{
+					Payload:       "OK",
+					StatusCode:    http.StatusOK,
+					StatusMessage: "OK",
+					Timestamp:     time.Now().Format(time.RFC3339Nano),
+				},
+			})
+		}
+		return
+	}
+
+	data, _ := forwardRequest(e.defaultBackend, "", w, r)
+	data.ClientIP = clientIP
+	data.ForwardedFor = forwardedFor
+	if e.recordEvent != nil {
+		e.recordEvent(data)
+	}
+}
@@ -0,0 +1,149 @@
+// rules_test.go
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeRule is a minimal Rule used to control exactly what Handle does,
+// without going through rule matching or a real upstream.
+type fakeRule struct {
+	name   string
+	handle func(w http.ResponseWriter, r *http.Request) (InspectrData, error)
+}
+
+func (f *fakeRule) Name() string               { return f.name }
+func (f *fakeRule) Match(r *http.Request) bool { return true }
+func (f *fakeRule) Handle(w http.ResponseWriter, r *http.Request) (InspectrData, error) {
+	return f.handle(w, r)
+}
+
+// TestRuleEngineServeHTTPDoesNotDoubleWriteOnHandleError verifies that when a
+// matched rule's Handle already wrote a response before returning an error
+// (as forwardRequest does on a down upstream), ServeHTTP does not also call
+// http.Error and write a second status/body.
+func TestRuleEngineServeHTTPDoesNotDoubleWriteOnHandleError(t *testing.T) {
+	rule := &fakeRule{
+		name: "already-wrote",
+		handle: func(w http.ResponseWriter, r *http.Request) (InspectrData, error) {
+			http.Error(w, "upstream down", http.StatusBadGateway)
+			return InspectrData{}, errors.New("upstream down")
+		},
+	}
+	ipResolver, err := newClientIPResolver("", nil)
+	if err != nil {
+		t.Fatalf("newClientIPResolver: %v", err)
+	}
+	engine := NewRuleEngine([]Rule{rule}, "", ipResolver, nil)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/anything", nil)
+	engine.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadGateway)
+	}
+	if got := rec.Body.String(); got != "upstream down\n" {
+		t.Fatalf("body = %q, want only the rule's own write", got)
+	}
+}
+
+// TestRuleEngineServeHTTPWritesErrorOnHandleFailure verifies that when a
+// matched rule's Handle fails (handleMock failing to read its BodyFile, which
+// writes its own 500 via writeRuleError), the client gets that error status
+// instead of an implicit 200 OK, and the event is still recorded with it.
+func TestRuleEngineServeHTTPWritesErrorOnHandleFailure(t *testing.T) {
+	def := RuleDef{
+		Name:  "missing-bodyfile",
+		Match: MatchDef{Path: "/mock"},
+		Mock:  &MockAction{BodyFile: "/nonexistent/does-not-exist.json"},
+	}
+	rules, err := compileRules(&RuleConfig{Rules: []RuleDef{def}}, nil)
+	if err != nil {
+		t.Fatalf("compileRules: %v", err)
+	}
+	ipResolver, err := newClientIPResolver("", nil)
+	if err != nil {
+		t.Fatalf("newClientIPResolver: %v", err)
+	}
+	var captured InspectrData
+	engine := NewRuleEngine(rules, "", ipResolver, func(data InspectrData) {
+		captured = data
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/mock", nil)
+	engine.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if captured.Response.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("captured status = %d, want %d", captured.Response.StatusCode, http.StatusInternalServerError)
+	}
+}
+
+// TestHandleFaultHonorsProbabilityWithoutForward verifies that a fault rule
+// with no Forward configured only returns the fault body when the
+// probability draw says it should, rather than on every request.
+func TestHandleFaultHonorsProbabilityWithoutForward(t *testing.T) {
+	never := &compiledRule{def: RuleDef{
+		Name:  "never-fault",
+		Fault: &FaultAction{Status: http.StatusTeapot, Probability: 0},
+	}}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := never.Handle(rec, req); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d (pass-through, probability 0)", rec.Code, http.StatusOK)
+	}
+
+	always := &compiledRule{def: RuleDef{
+		Name:  "always-fault",
+		Fault: &FaultAction{Status: http.StatusTeapot, Probability: 1},
+	}}
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := always.Handle(rec, req); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("status = %d, want %d (fault, probability 1)", rec.Code, http.StatusTeapot)
+	}
+}
+
+// TestRuleEngineServeHTTPSetsClientIP verifies that ServeHTTP enriches the
+// InspectrData returned by a matched rule with the resolved client IP,
+// mirroring proxyHandler's behavior.
+func TestRuleEngineServeHTTPSetsClientIP(t *testing.T) {
+	rule := &fakeRule{
+		name: "mock",
+		handle: func(w http.ResponseWriter, r *http.Request) (InspectrData, error) {
+			w.WriteHeader(http.StatusOK)
+			return InspectrData{Method: r.Method, Path: r.URL.Path}, nil
+		},
+	}
+	ipResolver, err := newClientIPResolver("", nil)
+	if err != nil {
+		t.Fatalf("newClientIPResolver: %v", err)
+	}
+	var captured InspectrData
+	engine := NewRuleEngine([]Rule{rule}, "", ipResolver, func(data InspectrData) {
+		captured = data
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/mock", nil)
+	req.RemoteAddr = "203.0.113.5:1234"
+	engine.ServeHTTP(rec, req)
+
+	if captured.ClientIP != "203.0.113.5" {
+		t.Fatalf("ClientIP = %q, want %q", captured.ClientIP, "203.0.113.5")
+	}
+}
@@ -0,0 +1,87 @@
+// sinks_test.go
+
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// blockingSink does not complete any Send until unblock is closed, simulating
+// a wedged destination.
+type blockingSink struct{ unblock chan struct{} }
+
+func (b *blockingSink) Send(ctx context.Context, event CloudEvent) error {
+	<-b.unblock
+	return nil
+}
+func (b *blockingSink) Close() error { return nil }
+
+// countingSink records every event it receives.
+type countingSink struct {
+	received chan CloudEvent
+}
+
+func (c *countingSink) Send(ctx context.Context, event CloudEvent) error {
+	c.received <- event
+	return nil
+}
+func (c *countingSink) Close() error { return nil }
+
+// TestSinkManagerIsolatesSlowSink verifies that a sink whose worker is stuck
+// delivering (queue full, dropPolicyBlock) does not prevent a second sink
+// from receiving events dispatched after it.
+func TestSinkManagerIsolatesSlowSink(t *testing.T) {
+	stuck := &blockingSink{unblock: make(chan struct{})}
+	fast := &countingSink{received: make(chan CloudEvent, 10)}
+
+	// queueSize 1 so the stuck sink's worker queue is full from the second
+	// event on, forcing every subsequent Dispatch to try to enqueue to it.
+	manager := NewSinkManager([]Sink{stuck, fast}, 1, dropPolicyBlock)
+	defer func() {
+		close(stuck.unblock)
+		manager.Close()
+	}()
+
+	// The first event is pulled off the stuck sink's queue into its blocked
+	// Send call, the second fills the now-empty queue, and the third would
+	// block forever on enqueue under a sequential fan-out.
+	manager.Dispatch(CloudEvent{ID: "1"})
+	manager.Dispatch(CloudEvent{ID: "2"})
+	manager.Dispatch(CloudEvent{ID: "3"})
+
+	seen := map[string]bool{}
+	for len(seen) < 3 {
+		select {
+		case event := <-fast.received:
+			seen[event.ID] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("fast sink only received %v while the other sink was stuck", seen)
+		}
+	}
+}
+
+// TestNewSinkFromURIStripsBatchQueryParams verifies that the flush/max query
+// parameters consumed to configure an http+batch sink are not also left on
+// the endpoint used as the POST target.
+func TestNewSinkFromURIStripsBatchQueryParams(t *testing.T) {
+	sink, err := newSinkFromURI("http+batch://collector/events?flush=1s&max=100")
+	if err != nil {
+		t.Fatalf("newSinkFromURI: %v", err)
+	}
+	defer func() {
+		sink.Close()
+		os.RemoveAll(walDirFor(sink.(*HTTPSink).endpoint))
+	}()
+
+	httpSink, ok := sink.(*HTTPSink)
+	if !ok {
+		t.Fatalf("sink type = %T, want *HTTPSink", sink)
+	}
+	const want = "http://collector/events"
+	if httpSink.endpoint != want {
+		t.Fatalf("endpoint = %q, want %q", httpSink.endpoint, want)
+	}
+}
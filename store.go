@@ -0,0 +1,344 @@
+// store.go
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotFound is returned by Store.Get when no event matches the given ID.
+var ErrNotFound = errors.New("event not found")
+
+// QueryFilter describes the criteria used to narrow down a Store.Query call.
+// Zero values mean "no constraint" for that field.
+type QueryFilter struct {
+	Method       string    // HTTP method match, case-insensitive, e.g. "GET"
+	PathGlob     string    // glob pattern matched against InspectrData.Path
+	StatusMin    int       // inclusive lower bound on Response.StatusCode
+	StatusMax    int       // inclusive upper bound on Response.StatusCode
+	Since        time.Time // only events at or after this time
+	Until        time.Time // only events at or before this time
+	MinLatencyMs int64     // only events with Latency >= this value
+	Cursor       string    // opaque pagination cursor, the ID of the last seen event
+	Limit        int       // max number of events to return, 0 means no limit
+}
+
+// Store persists captured CloudEvents and makes them queryable and replayable.
+type Store interface {
+	// Append records a newly captured CloudEvent.
+	Append(event CloudEvent) error
+	// Query returns events matching filter, newest first, honoring Cursor/Limit for pagination.
+	Query(filter QueryFilter) ([]CloudEvent, error)
+	// Get returns the single event with the given ID, or ErrNotFound.
+	Get(id string) (CloudEvent, error)
+}
+
+// matchesFilter reports whether event satisfies all constraints in filter.
+func matchesFilter(event CloudEvent, filter QueryFilter) bool {
+	data := event.Data
+	if filter.Method != "" && !strings.EqualFold(data.Method, filter.Method) {
+		return false
+	}
+	if filter.PathGlob != "" {
+		ok, err := path.Match(filter.PathGlob, data.Path)
+		if err != nil || !ok {
+			return false
+		}
+	}
+	if filter.StatusMin != 0 && data.Response.StatusCode < filter.StatusMin {
+		return false
+	}
+	if filter.StatusMax != 0 && data.Response.StatusCode > filter.StatusMax {
+		return false
+	}
+	if filter.MinLatencyMs != 0 && data.Latency < filter.MinLatencyMs {
+		return false
+	}
+	if !filter.Since.IsZero() || !filter.Until.IsZero() {
+		ts, err := time.Parse(time.RFC3339Nano, event.Time)
+		if err != nil {
+			return false
+		}
+		if !filter.Since.IsZero() && ts.Before(filter.Since) {
+			return false
+		}
+		if !filter.Until.IsZero() && ts.After(filter.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+// paginate applies Cursor/Limit to a newest-first slice of events.
+func paginate(events []CloudEvent, filter QueryFilter) []CloudEvent {
+	if filter.Cursor != "" {
+		for i, e := range events {
+			if e.ID == filter.Cursor {
+				events = events[i+1:]
+				break
+			}
+		}
+	}
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events
+}
+
+// --- In-memory ring buffer store ---
+
+// MemoryStore is a Store backed by a fixed-size in-memory ring buffer. Once
+// the buffer is full, appending a new event evicts the oldest one.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	events []CloudEvent // ordered oldest to newest
+	maxLen int
+}
+
+// NewMemoryStore creates a MemoryStore that retains at most maxLen events.
+func NewMemoryStore(maxLen int) *MemoryStore {
+	if maxLen <= 0 {
+		maxLen = 1000
+	}
+	return &MemoryStore{maxLen: maxLen}
+}
+
+// Append implements Store.
+func (s *MemoryStore) Append(event CloudEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	if len(s.events) > s.maxLen {
+		s.events = s.events[len(s.events)-s.maxLen:]
+	}
+	return nil
+}
+
+// Query implements Store.
+func (s *MemoryStore) Query(filter QueryFilter) ([]CloudEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var matched []CloudEvent
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if matchesFilter(s.events[i], filter) {
+			matched = append(matched, s.events[i])
+		}
+	}
+	return paginate(matched, filter), nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(id string) (CloudEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, e := range s.events {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return CloudEvent{}, ErrNotFound
+}
+
+// Last returns the n most recently appended events, oldest first.
+func (s *MemoryStore) Last(n int) []CloudEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if n <= 0 || n > len(s.events) {
+		n = len(s.events)
+	}
+	out := make([]CloudEvent, n)
+	copy(out, s.events[len(s.events)-n:])
+	return out
+}
+
+// --- bolt-backed persistent store ---
+
+var eventsBucket = []byte("events")
+
+// BoltStore is a Store backed by a bbolt database file. Events are stored
+// keyed by a zero-padded sequence number so iteration order matches insertion order.
+type BoltStore struct {
+	db  *bolt.DB
+	mu  sync.Mutex
+	seq uint64
+}
+
+// NewBoltStore opens (or creates) a bbolt database at path for use as a Store.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt store: %w", err)
+	}
+	var seq uint64
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(eventsBucket)
+		if err != nil {
+			return err
+		}
+		// Continue the sequence after the last stored key so a reopened
+		// store appends after, rather than over, its existing events.
+		if k, _ := b.Cursor().Last(); k != nil {
+			seq, err = strconv.ParseUint(string(k), 10, 64)
+		}
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init bolt store: %w", err)
+	}
+	return &BoltStore{db: db, seq: seq}, nil
+}
+
+// Close releases the underlying database file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// Append implements Store.
+func (s *BoltStore) Append(event CloudEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	s.mu.Lock()
+	s.seq++
+	key := []byte(fmt.Sprintf("%020d", s.seq))
+	s.mu.Unlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(eventsBucket).Put(key, payload)
+	})
+}
+
+// Query implements Store.
+func (s *BoltStore) Query(filter QueryFilter) ([]CloudEvent, error) {
+	var matched []CloudEvent
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var event CloudEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				return fmt.Errorf("unmarshal event %s: %w", k, err)
+			}
+			if matchesFilter(event, filter) {
+				matched = append(matched, event)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paginate(matched, filter), nil
+}
+
+// Get implements Store.
+func (s *BoltStore) Get(id string) (CloudEvent, error) {
+	var found CloudEvent
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var event CloudEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				return fmt.Errorf("unmarshal event %s: %w", k, err)
+			}
+			if event.ID == id {
+				found = event
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return CloudEvent{}, err
+	}
+	return found, nil
+}
+
+// --- composing a durable store with an in-memory replay buffer ---
+
+// multiStore appends every event to several underlying stores but serves
+// Query/Get from the first one. It lets the proxy keep a bolt-backed store of
+// record while still feeding a cheap in-memory ring buffer used to replay the
+// last N events to freshly-connected SSE clients.
+type multiStore []Store
+
+// Append implements Store, writing to every underlying store.
+func (m multiStore) Append(event CloudEvent) error {
+	for _, s := range m {
+		if err := s.Append(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query implements Store using the first underlying store.
+func (m multiStore) Query(filter QueryFilter) ([]CloudEvent, error) {
+	return m[0].Query(filter)
+}
+
+// Get implements Store using the first underlying store.
+func (m multiStore) Get(id string) (CloudEvent, error) {
+	return m[0].Get(id)
+}
+
+// --- parsing helpers for the REST query API ---
+
+// parseQueryFilter builds a QueryFilter from the query string of an
+// /api/events request.
+func parseQueryFilter(values map[string][]string) (QueryFilter, error) {
+	filter := QueryFilter{
+		Method:   first(values, "method"),
+		PathGlob: first(values, "path"),
+		Cursor:   first(values, "cursor"),
+	}
+	var err error
+	if v := first(values, "statusMin"); v != "" {
+		if filter.StatusMin, err = strconv.Atoi(v); err != nil {
+			return filter, fmt.Errorf("invalid statusMin: %w", err)
+		}
+	}
+	if v := first(values, "statusMax"); v != "" {
+		if filter.StatusMax, err = strconv.Atoi(v); err != nil {
+			return filter, fmt.Errorf("invalid statusMax: %w", err)
+		}
+	}
+	if v := first(values, "minLatencyMs"); v != "" {
+		if filter.MinLatencyMs, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return filter, fmt.Errorf("invalid minLatencyMs: %w", err)
+		}
+	}
+	if v := first(values, "since"); v != "" {
+		if filter.Since, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return filter, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if v := first(values, "until"); v != "" {
+		if filter.Until, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return filter, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	filter.Limit = 100
+	if v := first(values, "limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return filter, fmt.Errorf("invalid limit: %w", err)
+		}
+	}
+	return filter, nil
+}
+
+func first(values map[string][]string, key string) string {
+	if v, ok := values[key]; ok && len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+// store_test.go
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func eventAt(id string, ts time.Time, status int, latencyMs int64) CloudEvent {
+	return CloudEvent{
+		ID:   id,
+		Time: ts.Format(time.RFC3339Nano),
+		Data: InspectrData{
+			Method:  "GET",
+			Path:    "/api/widgets",
+			Latency: latencyMs,
+			Response: ResponseDetails{
+				StatusCode: status,
+			},
+		},
+	}
+}
+
+// TestMatchesFilterStatusRange verifies that StatusMin/StatusMax bound the
+// matched events inclusively on both ends.
+func TestMatchesFilterStatusRange(t *testing.T) {
+	now := time.Now()
+	event := eventAt("1", now, 404, 0)
+
+	if !matchesFilter(event, QueryFilter{StatusMin: 400, StatusMax: 499}) {
+		t.Fatal("expected event with status 404 to match [400,499]")
+	}
+	if matchesFilter(event, QueryFilter{StatusMin: 500}) {
+		t.Fatal("expected event with status 404 not to match StatusMin 500")
+	}
+	if matchesFilter(event, QueryFilter{StatusMax: 399}) {
+		t.Fatal("expected event with status 404 not to match StatusMax 399")
+	}
+}
+
+// TestMatchesFilterMinLatency verifies MinLatencyMs excludes faster events.
+func TestMatchesFilterMinLatency(t *testing.T) {
+	event := eventAt("1", time.Now(), 200, 50)
+
+	if !matchesFilter(event, QueryFilter{MinLatencyMs: 50}) {
+		t.Fatal("expected event with latency 50ms to match MinLatencyMs 50")
+	}
+	if matchesFilter(event, QueryFilter{MinLatencyMs: 51}) {
+		t.Fatal("expected event with latency 50ms not to match MinLatencyMs 51")
+	}
+}
+
+// TestMatchesFilterTimeRange verifies Since/Until bound on the event's
+// parsed Time, and that an unparsable Time excludes the event rather than
+// panicking.
+func TestMatchesFilterTimeRange(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	event := eventAt("1", base, 200, 0)
+
+	if !matchesFilter(event, QueryFilter{Since: base.Add(-time.Minute), Until: base.Add(time.Minute)}) {
+		t.Fatal("expected event to fall within [Since,Until]")
+	}
+	if matchesFilter(event, QueryFilter{Since: base.Add(time.Minute)}) {
+		t.Fatal("expected event before Since not to match")
+	}
+	if matchesFilter(event, QueryFilter{Until: base.Add(-time.Minute)}) {
+		t.Fatal("expected event after Until not to match")
+	}
+
+	malformed := event
+	malformed.Time = "not-a-timestamp"
+	if matchesFilter(malformed, QueryFilter{Since: base.Add(-time.Minute)}) {
+		t.Fatal("expected an unparsable Time to exclude the event, not match")
+	}
+}
+
+// TestPaginateCursorAndLimit verifies that Cursor skips past the last-seen
+// ID (exclusive) and Limit then caps the remaining slice.
+func TestPaginateCursorAndLimit(t *testing.T) {
+	now := time.Now()
+	events := []CloudEvent{
+		eventAt("5", now, 200, 0),
+		eventAt("4", now, 200, 0),
+		eventAt("3", now, 200, 0),
+		eventAt("2", now, 200, 0),
+		eventAt("1", now, 200, 0),
+	}
+
+	page := paginate(events, QueryFilter{Cursor: "4", Limit: 2})
+	if len(page) != 2 {
+		t.Fatalf("len(page) = %d, want 2", len(page))
+	}
+	if page[0].ID != "3" || page[1].ID != "2" {
+		t.Fatalf("page IDs = [%s,%s], want [3,2]", page[0].ID, page[1].ID)
+	}
+}
+
+// TestPaginateCursorNotFoundReturnsAllEvents verifies that an unknown cursor
+// (e.g. referring to an event that has since been evicted) leaves the full
+// set intact rather than dropping everything.
+func TestPaginateCursorNotFoundReturnsAllEvents(t *testing.T) {
+	now := time.Now()
+	events := []CloudEvent{eventAt("2", now, 200, 0), eventAt("1", now, 200, 0)}
+
+	page := paginate(events, QueryFilter{Cursor: "unknown"})
+	if len(page) != 2 {
+		t.Fatalf("len(page) = %d, want 2", len(page))
+	}
+}
+
+// TestMemoryStoreQueryOrdersNewestFirstAndFilters exercises MemoryStore.Query
+// end to end: newest-first ordering and a status filter.
+func TestMemoryStoreQueryOrdersNewestFirstAndFilters(t *testing.T) {
+	store := NewMemoryStore(10)
+	now := time.Now()
+	for i, status := range []int{200, 404, 200, 500} {
+		if err := store.Append(eventAt(string(rune('1'+i)), now.Add(time.Duration(i)*time.Second), status, 0)); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	all, err := store.Query(QueryFilter{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(all) != 4 || all[0].ID != "4" {
+		t.Fatalf("Query() = %v, want 4 events newest (ID 4) first", all)
+	}
+
+	errs, err := store.Query(QueryFilter{StatusMin: 400})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("len(errs) = %d, want 2", len(errs))
+	}
+}
@@ -0,0 +1,387 @@
+// wsgrpc.go
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// protocolSet is the set of protocols enabled via --protocols, e.g. {"http", "ws", "grpc"}.
+type protocolSet map[string]bool
+
+// parseProtocols builds a protocolSet from a comma-separated --protocols value.
+// An empty value enables only "http", matching the previous buffered behavior.
+func parseProtocols(flagValue string) protocolSet {
+	set := protocolSet{}
+	if strings.TrimSpace(flagValue) == "" {
+		set["http"] = true
+		return set
+	}
+	for _, p := range strings.Split(flagValue, ",") {
+		if p = strings.TrimSpace(strings.ToLower(p)); p != "" {
+			set[p] = true
+		}
+	}
+	return set
+}
+
+// isWebSocketUpgrade reports whether r is a WebSocket upgrade handshake.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// isGRPCRequest reports whether r carries a gRPC payload, detected via the
+// application/grpc content type negotiated over HTTP/2.
+func isGRPCRequest(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// --- WebSocket proxying ---
+
+// websocketProxyHandler hijacks the client connection, dials the backend,
+// forwards the upgrade handshake, and then pipes frames bidirectionally while
+// decoding each WebSocket frame into an event for onCapture. ipResolver
+// resolves the client IP from the upgrade request, honoring trusted-proxy
+// headers the same as the plain-HTTP path.
+func websocketProxyHandler(backendAddr string, ipResolver *clientIPResolver, onCapture func(InspectrData)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		backendHost, err := backendTCPAddr(backendAddr)
+		if err != nil {
+			http.Error(w, "Invalid backend address", http.StatusInternalServerError)
+			return
+		}
+
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "Hijacking unsupported", http.StatusInternalServerError)
+			return
+		}
+		clientConn, clientBuf, err := hijacker.Hijack()
+		if err != nil {
+			http.Error(w, "Failed to hijack connection", http.StatusInternalServerError)
+			return
+		}
+		defer clientConn.Close()
+
+		backendConn, err := net.DialTimeout("tcp", backendHost, 10*time.Second)
+		if err != nil {
+			log.Println("WebSocket backend dial error:", err)
+			fmt.Fprintf(clientConn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+			return
+		}
+		defer backendConn.Close()
+
+		// Forward the upgrade request as-is to the backend.
+		if err := r.Write(backendConn); err != nil {
+			log.Println("WebSocket handshake forward error:", err)
+			return
+		}
+
+		// Relay the backend's handshake response back to the client.
+		backendReader := bufio.NewReader(backendConn)
+		resp, err := http.ReadResponse(backendReader, r)
+		if err != nil {
+			log.Println("WebSocket handshake response error:", err)
+			return
+		}
+		if err := resp.Write(clientConn); err != nil {
+			log.Println("WebSocket handshake relay error:", err)
+			return
+		}
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			return
+		}
+
+		clientIP, forwardedFor := ipResolver.Resolve(r)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			pipeWebSocketFrames(clientBuf, backendConn, "outbound", r.URL.Path, clientIP, forwardedFor, onCapture)
+		}()
+		go func() {
+			defer wg.Done()
+			pipeWebSocketFrames(backendReader, clientConn, "inbound", r.URL.Path, clientIP, forwardedFor, onCapture)
+		}()
+		wg.Wait()
+	}
+}
+
+// backendTCPAddr extracts the host:port to dial from a backend base URL such
+// as "http://localhost:9000".
+func backendTCPAddr(backendAddr string) (string, error) {
+	parsed, err := url.Parse(backendAddr)
+	if err != nil {
+		return "", err
+	}
+	host := parsed.Host
+	if host == "" {
+		host = backendAddr
+	}
+	return host, nil
+}
+
+// pipeWebSocketFrames copies raw bytes from src to dst while decoding
+// complete WebSocket frames read from src and reporting each as an event.
+func pipeWebSocketFrames(src io.Reader, dst io.Writer, direction, path, clientIP string, forwardedFor []string, onCapture func(InspectrData)) {
+	decoder := &wsFrameDecoder{}
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return
+			}
+			if onCapture != nil {
+				for _, payload := range decoder.feed(buf[:n]) {
+					onCapture(InspectrData{
+						Method:       "WS",
+						Path:         path,
+						ClientIP:     clientIP,
+						ForwardedFor: forwardedFor,
+						Protocol:     "websocket",
+						Direction:    direction,
+						Request: RequestDetails{
+							Payload:   payload,
+							Timestamp: time.Now().Format(time.RFC3339Nano),
+						},
+						Response: ResponseDetails{
+							Timestamp: time.Now().Format(time.RFC3339Nano),
+						},
+					})
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// WebSocket opcodes (RFC 6455 section 5.2) this decoder cares about
+// distinguishing: everything below 0x8 is a data frame (continuation,
+// text, or binary) that belongs to message reassembly, and everything from
+// 0x8 up is a control frame.
+const (
+	wsOpcodeClose byte = 0x8
+	wsOpcodePing  byte = 0x9
+	wsOpcodePong  byte = 0xa
+)
+
+// isWSControlOpcode reports whether opcode identifies a control frame
+// (close/ping/pong). Control frames are always unfragmented and, per RFC
+// 6455, may legally be injected between the fragments of a data message, so
+// they must never be folded into that message's payload.
+func isWSControlOpcode(opcode byte) bool {
+	return opcode >= wsOpcodeClose
+}
+
+// wsFrameDecoder incrementally parses RFC 6455 frames out of a byte stream,
+// reassembling fragmented messages, and returns the decoded text/binary
+// payload (as a string) of each completed message.
+type wsFrameDecoder struct {
+	buf     bytes.Buffer
+	message bytes.Buffer // accumulates fragments of the current message
+}
+
+// feed appends chunk to the decoder's buffer and returns the payloads of any
+// messages fully decoded as a result.
+func (d *wsFrameDecoder) feed(chunk []byte) []string {
+	d.buf.Write(chunk)
+	var messages []string
+	for {
+		payload, fin, opcode, ok := d.decodeOne()
+		if !ok {
+			break
+		}
+		if isWSControlOpcode(opcode) {
+			continue
+		}
+		d.message.Write(payload)
+		if fin {
+			messages = append(messages, d.message.String())
+			d.message.Reset()
+		}
+	}
+	return messages
+}
+
+// decodeOne attempts to decode a single frame from the front of d.buf,
+// returning its payload, its opcode, whether it was the final fragment of a
+// message, and whether a full frame was available.
+func (d *wsFrameDecoder) decodeOne() (payload []byte, fin bool, opcode byte, ok bool) {
+	raw := d.buf.Bytes()
+	if len(raw) < 2 {
+		return nil, false, 0, false
+	}
+	fin = raw[0]&0x80 != 0
+	opcode = raw[0] & 0x0f
+	masked := raw[1]&0x80 != 0
+	length := uint64(raw[1] & 0x7f)
+	offset := 2
+
+	switch length {
+	case 126:
+		if len(raw) < offset+2 {
+			return nil, false, 0, false
+		}
+		length = uint64(binary.BigEndian.Uint16(raw[offset:]))
+		offset += 2
+	case 127:
+		if len(raw) < offset+8 {
+			return nil, false, 0, false
+		}
+		length = binary.BigEndian.Uint64(raw[offset:])
+		offset += 8
+	}
+
+	var maskKey [4]byte
+	if masked {
+		if len(raw) < offset+4 {
+			return nil, false, 0, false
+		}
+		copy(maskKey[:], raw[offset:offset+4])
+		offset += 4
+	}
+
+	if uint64(len(raw)) < uint64(offset)+length {
+		return nil, false, 0, false
+	}
+
+	payload = make([]byte, length)
+	copy(payload, raw[offset:uint64(offset)+length])
+	if masked {
+		for i := range payload {
+			payload[i] ^= maskKey[i%4]
+		}
+	}
+
+	d.buf.Next(offset + int(length))
+	return payload, fin, opcode, true
+}
+
+// --- gRPC proxying ---
+
+// grpcProxyHandler proxies HTTP/2 gRPC traffic via httputil.ReverseProxy,
+// decoding each length-prefixed gRPC message from the request and response
+// bodies into an event tagged with the gRPC method name from the path.
+// ipResolver resolves the client IP from the request, honoring trusted-proxy
+// headers the same as the plain-HTTP path.
+func grpcProxyHandler(backendAddr string, ipResolver *clientIPResolver, onCapture func(InspectrData)) http.HandlerFunc {
+	target, err := url.Parse(backendAddr)
+	if err != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Invalid backend address", http.StatusInternalServerError)
+		}
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.FlushInterval = -1 // flush immediately so streamed messages aren't buffered
+
+	baseDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		clientIP, forwardedFor := ipResolver.Resolve(req)
+		baseDirector(req)
+		if onCapture != nil && req.Body != nil {
+			req.Body = &grpcFrameTee{
+				ReadCloser:   req.Body,
+				method:       req.URL.Path,
+				clientIP:     clientIP,
+				forwardedFor: forwardedFor,
+				direction:    "outbound",
+				onCapture:    onCapture,
+			}
+		}
+	}
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		if onCapture != nil && resp.Body != nil {
+			clientIP, forwardedFor := ipResolver.Resolve(resp.Request)
+			resp.Body = &grpcFrameTee{
+				ReadCloser:   resp.Body,
+				method:       resp.Request.URL.Path,
+				clientIP:     clientIP,
+				forwardedFor: forwardedFor,
+				direction:    "inbound",
+				onCapture:    onCapture,
+			}
+		}
+		return nil
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Println("gRPC proxy error:", err)
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+	}
+	return proxy.ServeHTTP
+}
+
+// grpcFrameTee wraps a gRPC request/response body, decoding each
+// length-prefixed message (1-byte compressed flag + 4-byte big-endian
+// length + payload) as it streams past and reporting it via onCapture.
+type grpcFrameTee struct {
+	io.ReadCloser
+	method       string
+	clientIP     string
+	forwardedFor []string
+	direction    string
+	onCapture    func(InspectrData)
+	buf          bytes.Buffer
+}
+
+// Read implements io.Reader, passing bytes through unmodified while feeding
+// a shadow buffer used to decode complete gRPC messages.
+func (g *grpcFrameTee) Read(p []byte) (int, error) {
+	n, err := g.ReadCloser.Read(p)
+	if n > 0 {
+		g.buf.Write(p[:n])
+		g.drainMessages()
+	}
+	return n, err
+}
+
+// drainMessages decodes any complete length-prefixed messages currently
+// buffered and reports each one.
+func (g *grpcFrameTee) drainMessages() {
+	for {
+		raw := g.buf.Bytes()
+		if len(raw) < 5 {
+			return
+		}
+		length := binary.BigEndian.Uint32(raw[1:5])
+		if uint64(len(raw)) < 5+uint64(length) {
+			return
+		}
+		payload := raw[5 : 5+length]
+		if g.onCapture != nil {
+			g.onCapture(InspectrData{
+				Method:       "gRPC",
+				Path:         g.method,
+				ClientIP:     g.clientIP,
+				ForwardedFor: g.forwardedFor,
+				Protocol:     "grpc",
+				Direction:    g.direction,
+				Request: RequestDetails{
+					Payload:   string(payload),
+					Timestamp: time.Now().Format(time.RFC3339Nano),
+				},
+				Response: ResponseDetails{
+					Timestamp: time.Now().Format(time.RFC3339Nano),
+				},
+			})
+		}
+		g.buf.Next(5 + int(length))
+	}
+}
@@ -0,0 +1,35 @@
+// wsgrpc_test.go
+
+package main
+
+import "testing"
+
+// wsFrame builds a minimal unmasked server-to-client WebSocket frame (as a
+// proxy would observe) with the given fin bit, opcode, and payload.
+func wsFrame(fin bool, opcode byte, payload []byte) []byte {
+	first := opcode & 0x0f
+	if fin {
+		first |= 0x80
+	}
+	return append([]byte{first, byte(len(payload))}, payload...)
+}
+
+// TestWSFrameDecoderIgnoresInterleavedControlFrame verifies that a ping
+// frame arriving between the fragments of a data message (legal per RFC
+// 6455) does not terminate or otherwise corrupt that message.
+func TestWSFrameDecoderIgnoresInterleavedControlFrame(t *testing.T) {
+	var decoder wsFrameDecoder
+
+	var frames []byte
+	frames = append(frames, wsFrame(false, 0x1, []byte("hello "))...) // text, not final
+	frames = append(frames, wsFrame(true, wsOpcodePing, []byte("ping"))...)
+	frames = append(frames, wsFrame(true, 0x0, []byte("world"))...) // continuation, final
+
+	messages := decoder.feed(frames)
+	if len(messages) != 1 {
+		t.Fatalf("messages = %v, want exactly one reassembled message", messages)
+	}
+	if messages[0] != "hello world" {
+		t.Fatalf("message = %q, want %q", messages[0], "hello world")
+	}
+}